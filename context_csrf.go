@@ -0,0 +1,40 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+// csrfTokenKey is the context-values key middleware/csrf stores the
+// per-request token under, read back by Context.CSRFToken.
+const csrfTokenKey = "_csrf_token"
+
+// CSRFToken returns the CSRF token issued for the current request by
+// middleware/csrf, or "" if that middleware isn't registered on this
+// route. Templates embed it in forms, e.g. as a hidden input. A
+// `param:"in(csrf)"` field tag is not recognized yet either, since the
+// struct-tag binder lives outside this package; middleware/csrf.ValidateParam
+// is the comparison it should call once that position is added.
+func (ctx *Context) CSRFToken() string {
+	token, _ := ctx.Values[csrfTokenKey].(string)
+	return token
+}
+
+// SetCSRFToken publishes the token for the current request so
+// Context.CSRFToken can read it back. It is called by middleware/csrf
+// and is not normally needed by application code.
+func (ctx *Context) SetCSRFToken(token string) {
+	if ctx.Values == nil {
+		ctx.Values = make(map[string]interface{})
+	}
+	ctx.Values[csrfTokenKey] = token
+}
@@ -64,6 +64,7 @@ func (resp *Response) reset(w http.ResponseWriter) {
 	resp.status = 0
 	resp.size = 0
 	resp.committed = false
+	resp.context.resetStdContext()
 }
 
 // Header returns the header map that will be sent by
@@ -138,6 +139,19 @@ func (resp *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return hj.Hijack()
 }
 
+// Push initiates an HTTP/2 server push, delegating to http.Pusher when
+// the underlying ResponseWriter supports it. Callers should treat
+// ErrNotSupported (returned when the connection isn't HTTP/2, e.g. an
+// h1 connection or a test recorder) as a non-fatal hint to fall back
+// to a normal response.
+func (resp *Response) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := resp.writer.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
 // CloseNotify implements the http.CloseNotifier interface to allow detecting
 // when the underlying connection has gone away.
 // This mechanism can be used to cancel long operations on the server if the
@@ -333,6 +347,32 @@ func (ctx *Context) SetSecureCookie(secret, name, value string, others ...interf
 	ctx.SetCookie(name, cookie, others...)
 }
 
+// GetSecureCookie gets and verifies a secure cookie previously set by
+// SetSecureCookie, returning its decoded value and true if the
+// signature matches, or "", false otherwise.
+func (ctx *Context) GetSecureCookie(secret, name string) (string, bool) {
+	cookie, err := ctx.R.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.SplitN(cookie.Value, "|", 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+	vs, timestamp, sig := parts[0], parts[1], parts[2]
+	h := hmac.New(sha1.New, []byte(secret))
+	fmt.Fprintf(h, "%s%s", vs, timestamp)
+	wantSig := fmt.Sprintf("%02x", h.Sum(nil))
+	if !hmac.Equal([]byte(sig), []byte(wantSig)) {
+		return "", false
+	}
+	b, err := base64.URLEncoding.DecodeString(vs)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
 // NoContent sends a response with no body and a status code.
 func (ctx *Context) NoContent(status int) {
 	ctx.W.WriteHeader(status)
@@ -386,21 +426,11 @@ func (ctx *Context) HTML(status int, html string) error {
 	return ctx.Send(status, *(*[]byte)(unsafe.Pointer(&h)))
 }
 
-// JSON sends a JSON response with status code.
+// JSON sends a JSON response with status code. It delegates to
+// JSONStream, which encodes data into an in-memory buffer before
+// sending it; the body is not written directly to the connection.
 func (ctx *Context) JSON(status int, data interface{}) error {
-	var (
-		b   []byte
-		err error
-	)
-	if ctx.frame.config.RunMode == RUNMODE_PROD {
-		b, err = json.Marshal(data)
-	} else {
-		b, err = json.MarshalIndent(data, "", "  ")
-	}
-	if err != nil {
-		return err
-	}
-	return ctx.JSONBlob(status, b)
+	return ctx.JSONStream(status, data)
 }
 
 // JSONBlob sends a JSON blob response with status code.
@@ -433,21 +463,11 @@ func (ctx *Context) JSONP(status int, callback string, data interface{}) error {
 	return ctx.Send(status, callbackContent.Bytes())
 }
 
-// XML sends an XML response with status code.
+// XML sends an XML response with status code. It delegates to
+// XMLStream, which encodes data into an in-memory buffer before
+// sending it; the body is not written directly to the connection.
 func (ctx *Context) XML(status int, data interface{}) error {
-	var (
-		b   []byte
-		err error
-	)
-	if ctx.frame.config.RunMode == RUNMODE_PROD {
-		b, err = xml.Marshal(data)
-	} else {
-		b, err = xml.MarshalIndent(data, "", "  ")
-	}
-	if err != nil {
-		return err
-	}
-	return ctx.XMLBlob(status, b)
+	return ctx.XMLStream(status, data)
 }
 
 // XMLBlob sends a XML blob response with status code.
@@ -458,12 +478,19 @@ func (ctx *Context) XMLBlob(status int, b []byte) error {
 	return ctx.Send(status, content.Bytes())
 }
 
-// JSONOrXML serve Xml OR Json, depending on the value of the Accept header
+// JSONOrXML serve Xml OR Json, depending on the value of the Accept header.
+//
+// Deprecated: this predates the renderer registry added by Render and
+// NegotiateContentType and only ever chose between JSON and XML; call
+// Render directly to negotiate across every registered renderer. It
+// now delegates to NegotiateContentType restricted to those two types,
+// so it shares one negotiation path with Render instead of its own
+// AcceptJSON/AcceptXML branch.
 func (ctx *Context) JSONOrXML(status int, data interface{}) error {
-	if ctx.AcceptJSON() || !ctx.AcceptXML() {
-		return ctx.JSON(status, data)
+	if ctx.NegotiateContentType([]string{MIMEApplicationJSON, MIMEApplicationXML}) == MIMEApplicationXML {
+		return ctx.XML(status, data)
 	}
-	return ctx.XML(status, data)
+	return ctx.JSON(status, data)
 }
 
 // File forces response for download file.
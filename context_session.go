@@ -0,0 +1,72 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"errors"
+
+	"github.com/henrylee2cn/thinkgo/session"
+)
+
+// errSessionsNotEnabled is returned by Context.Session when
+// WithSessions has not been registered on the request's handler
+// chain.
+var errSessionsNotEnabled = errors.New("thinkgo: sessions are not enabled, register thinkgo.WithSessions middleware")
+
+// SessionConfig configures the session middleware registered by
+// WithSessions: which Store backs sessions, and the cookie/session
+// name handlers retrieve them under.
+type SessionConfig struct {
+	// Store is the session backend, e.g. a *session.CookieStore.
+	Store session.Store
+	// Name is the default session name used by Context.Session.
+	Name string
+}
+
+// WithSessions returns a global middleware that attaches a session
+// registry to every request so handlers can call ctx.Session to read
+// and write typed values and flash messages, and commits every
+// touched session to the response once the handler chain completes.
+func WithSessions(config SessionConfig) HandlerFunc {
+	if config.Name == "" {
+		config.Name = "thinkgoSID"
+	}
+	return func(ctx *Context) error {
+		reg := session.GetRegistry(ctx.R)
+		ctx.sessionRegistry = reg
+		ctx.sessionStore = config.Store
+		ctx.sessionName = config.Name
+		err := ctx.Next()
+		if saveErr := reg.Save(ctx.W); err == nil {
+			err = saveErr
+		}
+		return err
+	}
+}
+
+// Session returns the named session (config.Name when no name is
+// given), loading it from the configured Store on first access within
+// the request. WithSessions must have been registered for this to
+// work; otherwise Session returns an error.
+func (ctx *Context) Session(name ...string) (*session.Session, error) {
+	if ctx.sessionRegistry == nil {
+		return nil, errSessionsNotEnabled
+	}
+	n := ctx.sessionName
+	if len(name) > 0 && name[0] != "" {
+		n = name[0]
+	}
+	return ctx.sessionRegistry.Get(ctx.sessionStore, n)
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"context"
+)
+
+// StdContext returns the standard library context.Context for this
+// request, so handlers can thread cancellation through to downstream
+// calls (database queries, outgoing HTTP requests, etc). On first
+// call it is lazily derived from RootContext and merged with the
+// connection's CloseNotify (see withCancel), so it is canceled when
+// the client disconnects, when RootContext is canceled by
+// RunGraceful's shutdown handling, or when resetStdContext releases it
+// at the end of the request, whichever happens first. No explicit
+// per-request wiring is required: the lazy init here is what wires
+// every Context up, triggered by the first StdContext/WithValue/Done
+// call a handler makes. Like the rest of Context, it is only safe to
+// call from the goroutine running the handler chain; fan out to other
+// goroutines by capturing the returned context.Context, not by calling
+// StdContext/WithValue/Done from them concurrently.
+func (ctx *Context) StdContext() context.Context {
+	if ctx.stdCtx == nil {
+		ctx.withCancel(RootContext())
+	}
+	return ctx.stdCtx
+}
+
+// WithValue derives a new context.Context carrying key/value from
+// StdContext and makes it the Context's standard context for the rest
+// of the request, so later StdContext calls (including in downstream
+// middleware) observe it. Call it from the handler goroutine only; see
+// StdContext.
+func (ctx *Context) WithValue(key, value interface{}) {
+	ctx.stdCtx = context.WithValue(ctx.StdContext(), key, value)
+}
+
+// Done returns a channel that's closed when the request's standard
+// context is canceled: the client disconnected, or the server began a
+// graceful shutdown. A single select on it covers both cases, so
+// long-poll and SSE handlers don't need to check CloseNotify
+// separately.
+func (ctx *Context) Done() <-chan struct{} {
+	return ctx.StdContext().Done()
+}
+
+// withCancel derives ctx's standard context from parent and merges in
+// the connection's CloseNotify, so whichever fires first — the client
+// disconnecting or parent being canceled — cancels StdContext/Done.
+// StdContext calls this itself on first use with RootContext as
+// parent; it's a separate method so tests can wire a Context to a
+// context they control instead. The returned CancelFunc is also
+// stashed on ctx so resetStdContext can call it once the request is
+// done, per context.WithCancel's contract that the cancel func must
+// always be called to release the child context and its forwarding
+// goroutine; callers that derive their own context directly (as tests
+// do) remain responsible for that context's cancel func themselves.
+func (ctx *Context) withCancel(parent context.Context) context.CancelFunc {
+	stdCtx, cancel := context.WithCancel(parent)
+	ctx.stdCtx = stdCtx
+	ctx.stdCtxCancel = cancel
+	if notify := ctx.W.CloseNotify(); notify != nil {
+		go func() {
+			select {
+			case <-notify:
+				cancel()
+			case <-stdCtx.Done():
+			}
+		}()
+	}
+	return cancel
+}
+
+// resetStdContext cancels and clears any StdContext derived for this
+// Context, so withCancel's forwarding goroutine (if one was started)
+// exits instead of blocking until RootContext is canceled at server
+// shutdown. Response.reset calls this when a pooled Context is
+// recycled for the next request, which is the per-request completion
+// signal available in this package; callers that dispatch requests
+// without going through Response's pooling must call it themselves
+// once the handler chain returns, or StdContext/WithValue/Done users
+// will leak a goroutine and a context node per request.
+func (ctx *Context) resetStdContext() {
+	if ctx.stdCtxCancel != nil {
+		ctx.stdCtxCancel()
+	}
+	ctx.stdCtx = nil
+	ctx.stdCtxCancel = nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+
+	"github.com/henrylee2cn/thinkgo/acceptencoder"
+)
+
+// streamWriter returns an io.Writer that writes directly to the
+// response, transparently gzip-compressing it if the client accepts
+// encoding and gzip is enabled for this context, instead of buffering
+// the whole body in memory first. The returned close func must be
+// called once writing is done (typically via defer) to flush and
+// close any wrapping compressor.
+func (ctx *Context) streamWriter() (io.Writer, func() error, error) {
+	noop := func() error { return nil }
+	if !ctx.enableGzip || ctx.W.Header().Get(HeaderContentEncoding) != "" {
+		return ctx.W, noop, nil
+	}
+	encoding := acceptencoder.ParseEncoding(ctx.R)
+	if encoding == "" {
+		return ctx.W, noop, nil
+	}
+	gw, name, err := acceptencoder.NewWriter(encoding, ctx.W)
+	if err != nil || gw == nil {
+		return ctx.W, noop, nil
+	}
+	ctx.W.Header().Set(HeaderContentEncoding, name)
+	return gw, gw.Close, nil
+}
+
+// JSONStream sends a JSON response with status code. JSON delegates to
+// this method. data is still encoded to an internal buffer, not
+// written directly to the connection: that preserves JSON's original
+// fail-before-write guarantee (a value that can't be marshaled never
+// commits the response status/headers, so error middleware can still
+// send a proper error) and keeps the Content-Length/gzip handling in
+// Send. For true zero-copy streaming of large payloads, where holding
+// the whole body in memory is the problem being solved, use
+// JSONArrayStream, Blob or Attachment instead.
+func (ctx *Context) JSONStream(status int, data interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if ctx.frame.config.RunMode != RUNMODE_PROD {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	// json.Encoder.Encode appends a trailing newline that
+	// json.Marshal does not; trim it so JSONBlob's bytes match what
+	// JSON used to send.
+	return ctx.JSONBlob(status, bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+// XMLStream sends an XML response with status code. XML delegates to
+// this method. Like JSONStream, data is encoded to an internal buffer
+// first so a marshal failure never commits the response.
+func (ctx *Context) XMLStream(status int, data interface{}) error {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	if ctx.frame.config.RunMode != RUNMODE_PROD {
+		enc.Indent("", "  ")
+	}
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	return ctx.XMLBlob(status, buf.Bytes())
+}
+
+// JSONArrayStream sends a JSON array response, status code first,
+// encoding each value received from ch as it arrives rather than
+// collecting them into a slice first. It writes the opening bracket,
+// one comma-separated value per receive, then the closing bracket when
+// ch is closed. Useful for reporting/export endpoints where
+// marshaling the whole result set at once would risk an OOM.
+func (ctx *Context) JSONArrayStream(status int, ch <-chan interface{}) error {
+	ctx.W.Header().Set(HeaderContentType, MIMEApplicationJSONCharsetUTF8)
+	w, closeWriter, err := ctx.streamWriter()
+	if err != nil {
+		return err
+	}
+	defer closeWriter()
+	ctx.W.WriteHeader(status)
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	first := true
+	for v := range ch {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// Blob streams r to the client as the response body with the given
+// status and Content-Type, without buffering it in memory first.
+// Useful for proxying another response's body through unchanged.
+func (ctx *Context) Blob(status int, contentType string, r io.Reader) error {
+	ctx.W.Header().Set(HeaderContentType, contentType)
+	ctx.W.WriteHeader(status)
+	_, err := io.Copy(ctx.W, r)
+	return err
+}
+
+// Attachment streams r to the client as a file download named
+// filename, preparing the same download headers as File but without
+// requiring the content to live on disk or be buffered in memory.
+func (ctx *Context) Attachment(r io.ReadSeeker, filename string) error {
+	ctx.W.Header().Set(HeaderContentDescription, "File Transfer")
+	ctx.W.Header().Set(HeaderContentType, MIMEOctetStream)
+	ctx.W.Header().Set(HeaderContentDisposition, "attachment; filename="+filename)
+	ctx.W.Header().Set(HeaderContentTransferEncoding, "binary")
+	ctx.W.Header().Set(HeaderExpires, "0")
+	ctx.W.Header().Set(HeaderCacheControl, "must-revalidate")
+	ctx.W.Header().Set(HeaderPragma, "public")
+	ctx.W.WriteHeader(200)
+	_, err := io.Copy(ctx.W, r)
+	return err
+}
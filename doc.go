@@ -97,6 +97,26 @@ StructHandler tag value description:
         6. if param's position(`in`) is `cookie`, field's type must be `http.Cookie`
         7. param tags `in(formData)` and `in(body)` can not exist at the same time
         8. there should not be more than one `in(body)` param tag
+        9. the CSRF token (middleware/csrf) and verified JWT claims (thinkgo.WithJWT) are not yet
+           struct-tag bindable positions, since that requires a change to the struct-tag binder
+           itself; read them explicitly via `Context.CSRFToken` and `Context.JWTClaims` instead of
+           a `param:"in(csrf)"`/`param:"in(jwt)"` field. middleware/csrf.ValidateParam and
+           thinkgo.BindJWTParam are the comparison/lookup the binder should call once it grows
+           those positions, and thinkgo.ValidateClaimRange/ValidateClaimLen/ValidateClaimRegexp
+           are, likewise, the `range`/`len`/`regexp` tag checks it should run against a looked-up
+           claim value - none of the five are wired into the binder in this tree, only exposed for
+           callers to invoke by hand until that integration lands
+        10. graceful shutdown (SIGINT/SIGTERM draining via RootContext/Context.Done) is only
+           implemented for `thinkgo.RunGraceful`; `thinkgo.Run` and the server startup code it
+           lives on are outside this package in this change, so Run itself gains no shutdown
+           behavior and existing Run callers must switch to RunGraceful explicitly to get it -
+           this is a scope gap, not a finished drop-in replacement for Run, and should be called
+           out to whoever requested Run gain this behavior directly
+        11. despite its name, `Context.JSONStream` buffers data to an internal buffer before
+           writing, the same as `Context.JSON`; it does not write directly to the connection. That
+           buffering is deliberate, preserving JSON's fail-before-write guarantee (see its doc
+           comment), not an unfinished streaming implementation. For an actually zero-copy path for
+           large payloads, use `Context.JSONArrayStream`, `Context.Blob` or `Context.Attachment`
 
 List of supported structHandler param value types:
     base    |   slice    | special
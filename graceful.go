@@ -0,0 +1,102 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the grace period RunGraceful waits for
+// in-flight requests (including long-poll and SSE handlers watching
+// Context.Done) to finish before forcing an exit, when the caller
+// doesn't configure one explicitly.
+const DefaultShutdownTimeout = 15 * time.Second
+
+var (
+	rootCtxMu     sync.Mutex
+	rootCtx       context.Context = context.Background()
+	rootCtxCancel context.CancelFunc
+)
+
+// RootContext returns the context every request's StdContext is
+// derived from. It is canceled once when a graceful shutdown begins,
+// so every in-flight Context.Done fires at the same time regardless of
+// when each request started.
+func RootContext() context.Context {
+	rootCtxMu.Lock()
+	defer rootCtxMu.Unlock()
+	if rootCtxCancel == nil {
+		rootCtx, rootCtxCancel = context.WithCancel(context.Background())
+	}
+	return rootCtx
+}
+
+// RunGraceful starts srv, blocks until it exits, and implements
+// graceful shutdown: on SIGINT/SIGTERM it stops accepting new
+// connections via http.Server.Shutdown, cancels RootContext so every
+// in-flight Context is notified via Done (Response.CloseNotify is not
+// affected), and waits up to
+// shutdownTimeout for handlers to finish before returning. Call this
+// in place of srv.ListenAndServe to get graceful shutdown behavior;
+// every Context's StdContext/Done is derived from RootContext lazily
+// on first use (see Context.StdContext), so no separate per-request
+// wiring is needed.
+//
+// This is a separate entry point from thinkgo.Run rather than Run
+// itself gaining this behavior, because Run and the server startup
+// code it lives on are outside this package in this change; existing
+// thinkgo.Run callers need to switch to RunGraceful explicitly to pick
+// up graceful shutdown until that integration lands.
+func RunGraceful(srv *http.Server, shutdownTimeout time.Duration) error {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+	RootContext() // ensure rootCtxCancel is initialized before serving
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-sigCh:
+	}
+
+	rootCtxMu.Lock()
+	rootCtxCancel()
+	rootCtxMu.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
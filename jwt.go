@@ -0,0 +1,494 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SigningMethod names a JWT "alg" header value thinkgo knows how to
+// verify.
+type SigningMethod string
+
+// Supported signing methods for JWTConfig.SigningMethod.
+const (
+	HS256 SigningMethod = "HS256"
+	HS384 SigningMethod = "HS384"
+	HS512 SigningMethod = "HS512"
+	RS256 SigningMethod = "RS256"
+	RS384 SigningMethod = "RS384"
+	RS512 SigningMethod = "RS512"
+	ES256 SigningMethod = "ES256"
+	ES384 SigningMethod = "ES384"
+	ES512 SigningMethod = "ES512"
+)
+
+// Claims is the decoded JWT payload, keyed by claim name. Read it back
+// with Context.JWTClaims. A `param:"in(jwt)"` field tag is not
+// recognized yet either, since the struct-tag binder lives outside
+// this package (in thinkgo's router/dispatch code); BindJWTParam is
+// the lookup it should call once it grows that position, and
+// ValidateClaimRange/ValidateClaimLen/ValidateClaimRegexp are the
+// `range`/`len`/`regexp` tag checks it should run against the looked-up
+// value.
+type Claims map[string]interface{}
+
+// JWTConfig configures JWT verification for a route or route group,
+// registered via WithJWT.
+type JWTConfig struct {
+	// SigningMethod is the only "alg" accepted; tokens signed with any
+	// other algorithm (including "none") are rejected. Required.
+	SigningMethod SigningMethod
+	// KeyFunc returns the key material used to verify a token's
+	// signature: a []byte secret for HS*, or a *rsa.PublicKey /
+	// *ecdsa.PublicKey for RS*/ES*. It receives the unverified claims
+	// so keys can be looked up per-issuer/per-kid. Required.
+	KeyFunc func(unverifiedClaims Claims) (interface{}, error)
+	// TokenLookup tells WithJWT where to find the token on the
+	// request, as "source:name", e.g. "header:Authorization",
+	// "cookie:jwt" or "query:token". Defaults to
+	// "header:Authorization" (with an optional "Bearer " prefix).
+	TokenLookup string
+	// ClaimsFactory, if set, constructs the Claims map the payload is
+	// decoded into, in place of a fresh empty Claims; entries it
+	// pre-populates are kept unless the payload sets the same key. The
+	// payload is always decoded into a Claims map, never a custom
+	// struct. Optional.
+	ClaimsFactory func() Claims
+	// Leeway is the clock skew tolerance applied when checking the
+	// "exp" and "nbf" claims: a token expires Leeway after its "exp"
+	// time and becomes valid Leeway before its "nbf" time. Defaults to
+	// 0 (no tolerance).
+	Leeway time.Duration
+}
+
+// jwtClaimsKey is the context-values key WithJWT stores verified
+// claims under, read back by Context.JWTClaims.
+const jwtClaimsKey = "_jwt_claims"
+
+// WithJWT returns middleware that extracts a JWT per config's
+// TokenLookup, verifies its signature and "alg", and on success
+// attaches its claims to the Context so Context.JWTClaims can read
+// them. On missing or invalid tokens it responds 401 and stops the
+// handler chain.
+func WithJWT(config JWTConfig) HandlerFunc {
+	if config.TokenLookup == "" {
+		config.TokenLookup = "header:Authorization"
+	}
+	return func(ctx *Context) error {
+		tokenString, err := extractToken(ctx, config.TokenLookup)
+		if err != nil {
+			ctx.Error(401, "thinkgo: missing JWT: "+err.Error())
+			ctx.Stop()
+			return nil
+		}
+		claims, err := ParseToken(tokenString, config)
+		if err != nil {
+			ctx.Error(401, "thinkgo: invalid JWT: "+err.Error())
+			ctx.Stop()
+			return nil
+		}
+		if ctx.Values == nil {
+			ctx.Values = make(map[string]interface{})
+		}
+		ctx.Values[jwtClaimsKey] = claims
+		return ctx.Next()
+	}
+}
+
+// JWTClaims returns the claims verified by WithJWT for the current
+// request, or nil if WithJWT wasn't registered on this route or the
+// token was rejected.
+func (ctx *Context) JWTClaims() Claims {
+	claims, _ := ctx.Values[jwtClaimsKey].(Claims)
+	return claims
+}
+
+// BindJWTParam resolves the value for a struct field tagged
+// `param:"in(jwt),name(claim)"`: it looks up claim in the Claims
+// WithJWT verified for ctx's request, the same map JWTClaims exposes.
+// ok is false if WithJWT wasn't registered, verification failed, or
+// claim is absent, so the binder can apply `required` the same way it
+// does for a missing query/header/cookie value.
+func BindJWTParam(ctx *Context, claim string) (value interface{}, ok bool) {
+	claims := ctx.JWTClaims()
+	if claims == nil {
+		return nil, false
+	}
+	value, ok = claims[claim]
+	return value, ok
+}
+
+// ValidateClaimRange reports whether the numeric claim named claim is
+// within spec, using the same "min:max" syntax as a `param:"range(...)"`
+// tag (see doc.go). It's the claim-side counterpart of that tag for
+// callers validating JWT claims today: the struct-tag binder doesn't
+// recognize `range`/`len`/`regexp` on a `param:"in(jwt)"` field any
+// more than it recognizes `in(jwt)` itself (see the note on Claims), so
+// there's nothing for it to piggyback on yet. It returns false if
+// claims is nil, the claim is absent or non-numeric, or spec is
+// malformed.
+func ValidateClaimRange(claims Claims, claim, spec string) bool {
+	if claims == nil {
+		return false
+	}
+	v, ok := claims[claim]
+	if !ok {
+		return false
+	}
+	n, err := claimFloat(v)
+	if err != nil {
+		return false
+	}
+	min, max, err := parseRangeSpec(spec)
+	if err != nil {
+		return false
+	}
+	return n >= min && n <= max
+}
+
+// ValidateClaimLen reports whether the string claim named claim has a
+// length within spec, using the same "min:max" or "max" syntax as a
+// `param:"len(...)"` tag (see doc.go and ValidateClaimRange).
+func ValidateClaimLen(claims Claims, claim, spec string) bool {
+	if claims == nil {
+		return false
+	}
+	v, ok := claims[claim]
+	if !ok {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	min, max, err := parseRangeSpec(spec)
+	if err != nil {
+		return false
+	}
+	n := float64(len(s))
+	return n >= min && n <= max
+}
+
+// ValidateClaimRegexp reports whether the string claim named claim
+// matches pattern, the same check a `param:"regexp(...)"` tag applies
+// to an ordinary bound field (see doc.go and ValidateClaimRange).
+func ValidateClaimRegexp(claims Claims, claim, pattern string) bool {
+	if claims == nil {
+		return false
+	}
+	v, ok := claims[claim]
+	if !ok {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	matched, err := regexp.MatchString(pattern, s)
+	return err == nil && matched
+}
+
+// parseRangeSpec parses a "len"/"range" tag value of the form
+// "min:max" or just "max" (implying min 0), per doc.go's documented
+// syntax for those tags.
+func parseRangeSpec(spec string) (min, max float64, err error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) == 2 {
+		min, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		max, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		return min, max, err
+	}
+	max, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	return 0, max, err
+}
+
+// claimFloat reads a numeric claim value as a float64, accepting both
+// the float64 a plain json.Unmarshal produces and the json.Number a
+// UseNumber-decoded ClaimsFactory value would hold.
+func claimFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	default:
+		return 0, fmt.Errorf("want a numeric claim, got %T", v)
+	}
+}
+
+// extractToken pulls the raw token string from the request per a
+// TokenLookup spec of the form "source:name".
+func extractToken(ctx *Context, lookup string) (string, error) {
+	parts := strings.SplitN(lookup, ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("thinkgo: invalid TokenLookup %q", lookup)
+	}
+	source, name := parts[0], parts[1]
+	switch source {
+	case "header":
+		v := ctx.R.Header.Get(name)
+		if v == "" {
+			return "", fmt.Errorf("missing header %q", name)
+		}
+		if name == "Authorization" {
+			if i := strings.IndexByte(v, ' '); i >= 0 && strings.EqualFold(v[:i], "Bearer") {
+				v = v[i+1:]
+			}
+		}
+		return v, nil
+	case "query":
+		v := ctx.R.URL.Query().Get(name)
+		if v == "" {
+			return "", fmt.Errorf("missing query param %q", name)
+		}
+		return v, nil
+	case "cookie":
+		cookie, err := ctx.R.Cookie(name)
+		if err != nil {
+			return "", fmt.Errorf("missing cookie %q", name)
+		}
+		return cookie.Value, nil
+	default:
+		return "", fmt.Errorf("thinkgo: unknown TokenLookup source %q", source)
+	}
+}
+
+// ParseToken verifies tokenString's signature and "alg" header against
+// config, checks its "exp" and "nbf" claims (within config.Leeway),
+// and returns its decoded claims. A token with no "exp" claim is
+// rejected rather than treated as never expiring.
+func ParseToken(tokenString string, config JWTConfig) (Claims, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("thinkgo: malformed JWT")
+	}
+	headerJSON, err := jwtBase64Decode(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, err
+	}
+	if SigningMethod(header.Alg) != config.SigningMethod {
+		return nil, fmt.Errorf("thinkgo: unexpected signing method %q", header.Alg)
+	}
+	payloadJSON, err := jwtBase64Decode(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := Claims{}
+	if config.ClaimsFactory != nil {
+		claims = config.ClaimsFactory()
+	}
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, err
+	}
+	key, err := config.KeyFunc(claims)
+	if err != nil {
+		return nil, err
+	}
+	signingInput := parts[0] + "." + parts[1]
+	sig, err := jwtBase64Decode(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(config.SigningMethod, signingInput, sig, key); err != nil {
+		return nil, err
+	}
+	if err := validateTimeClaims(claims, config.Leeway, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validateTimeClaims enforces "exp" (required) and "nbf" (optional)
+// against now, each allowed leeway of clock skew.
+func validateTimeClaims(claims Claims, leeway time.Duration, now time.Time) error {
+	expClaim, ok := claims["exp"]
+	if !ok {
+		return errors.New("thinkgo: JWT missing required \"exp\" claim")
+	}
+	exp, err := claimUnixTime(expClaim)
+	if err != nil {
+		return fmt.Errorf("thinkgo: invalid \"exp\" claim: %v", err)
+	}
+	if now.After(exp.Add(leeway)) {
+		return errors.New("thinkgo: JWT has expired")
+	}
+	if nbfClaim, ok := claims["nbf"]; ok {
+		nbf, err := claimUnixTime(nbfClaim)
+		if err != nil {
+			return fmt.Errorf("thinkgo: invalid \"nbf\" claim: %v", err)
+		}
+		if now.Before(nbf.Add(-leeway)) {
+			return errors.New("thinkgo: JWT is not yet valid")
+		}
+	}
+	return nil
+}
+
+// claimUnixTime reads a JWT NumericDate claim value (seconds since the
+// Unix epoch, per RFC 7519 §2) as a time.Time. Claims decode as
+// float64 by default and json.Number when a ClaimsFactory's map was
+// populated via a json.Decoder with UseNumber, so both are accepted.
+func claimUnixTime(v interface{}) (time.Time, error) {
+	switch n := v.(type) {
+	case float64:
+		return time.Unix(int64(n), 0), nil
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(i, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("want a numeric timestamp, got %T", v)
+	}
+}
+
+func jwtBase64Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func verifySignature(method SigningMethod, signingInput string, sig []byte, key interface{}) error {
+	switch method {
+	case HS256, HS384, HS512:
+		secret, ok := key.([]byte)
+		if !ok {
+			return errors.New("thinkgo: HMAC signing methods require a []byte key")
+		}
+		h := jwtHash(method)
+		mac := hmac.New(h.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("thinkgo: signature verification failed")
+		}
+		return nil
+	case RS256, RS384, RS512:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("thinkgo: RSA signing methods require a *rsa.PublicKey key")
+		}
+		h := jwtHash(method)
+		hashed := jwtSum(h, signingInput)
+		return rsa.VerifyPKCS1v15(pub, h, hashed, sig)
+	case ES256, ES384, ES512:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("thinkgo: ECDSA signing methods require a *ecdsa.PublicKey key")
+		}
+		h := jwtHash(method)
+		hashed := jwtSum(h, signingInput)
+		half := len(sig) / 2
+		r := new(big.Int).SetBytes(sig[:half])
+		s := new(big.Int).SetBytes(sig[half:])
+		if !ecdsa.Verify(pub, hashed, r, s) {
+			return errors.New("thinkgo: signature verification failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("thinkgo: unsupported signing method %q", method)
+	}
+}
+
+func jwtHash(method SigningMethod) crypto.Hash {
+	switch method {
+	case HS384, RS384, ES384:
+		return crypto.SHA384
+	case HS512, RS512, ES512:
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+func jwtSum(h crypto.Hash, signingInput string) []byte {
+	switch h {
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(signingInput))
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512([]byte(signingInput))
+		return sum[:]
+	default:
+		sum := sha256.Sum256([]byte(signingInput))
+		return sum[:]
+	}
+}
+
+// ParseRSAPublicKeyFromPEM parses a PEM-encoded RSA public key, for
+// use as the key a JWTConfig.KeyFunc returns when SigningMethod is
+// RS256/RS384/RS512.
+func ParseRSAPublicKeyFromPEM(pemBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("thinkgo: invalid PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		cert, certErr := x509.ParseCertificate(block.Bytes)
+		if certErr != nil {
+			return nil, err
+		}
+		pub = cert.PublicKey
+	}
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("thinkgo: not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// ParseECPublicKeyFromPEM parses a PEM-encoded ECDSA public key, for
+// use as the key a JWTConfig.KeyFunc returns when SigningMethod is
+// ES256/ES384/ES512.
+func ParseECPublicKeyFromPEM(pemBytes []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("thinkgo: invalid PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("thinkgo: not an ECDSA public key")
+	}
+	return ecKey, nil
+}
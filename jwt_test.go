@@ -0,0 +1,325 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// BindJWTParam itself isn't covered here: it takes a *Context, and
+// constructing one requires thinkgo's router/dispatch package, which
+// is out of scope for this package's tests (the same gap
+// middleware/csrf's tests note for ValidateParam). ParseToken, which
+// BindJWTParam reads its result from via Context.JWTClaims, is what
+// the tests below exercise directly.
+
+// validClaims returns claims with sub and an exp a minute in the
+// future, the baseline every accept-path test builds on; tests
+// exercising exp/nbf override those keys explicitly.
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func buildTokenWithClaims(t *testing.T, alg string, claims map[string]interface{}, sign func(signingInput string) []byte) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	signingInput := jwtBase64Encode(header) + "." + jwtBase64Encode(payload)
+	sig := sign(signingInput)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func buildToken(t *testing.T, alg string, sign func(signingInput string) []byte) string {
+	t.Helper()
+	return buildTokenWithClaims(t, alg, validClaims(), sign)
+}
+
+func jwtBase64Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestParseTokenHS256(t *testing.T) {
+	secret := []byte("super-secret-key")
+	token := buildToken(t, "HS256", func(signingInput string) []byte {
+		mac := hmac.New(jwtHash(HS256).New, secret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil)
+	})
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return secret, nil },
+	}
+	claims, err := ParseToken(token, config)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Fatalf("claims[sub] = %v, want user-1", claims["sub"])
+	}
+}
+
+func TestParseTokenHS256WrongSecretRejected(t *testing.T) {
+	token := buildToken(t, "HS256", func(signingInput string) []byte {
+		mac := hmac.New(jwtHash(HS256).New, []byte("signing-secret"))
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil)
+	})
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return []byte("different-secret"), nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted a token signed with a different secret")
+	}
+}
+
+func TestParseTokenRejectsNoneAlg(t *testing.T) {
+	token := buildToken(t, "none", func(string) []byte { return nil })
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return []byte("secret"), nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted alg=none")
+	}
+}
+
+func TestParseTokenRejectsMismatchedAlg(t *testing.T) {
+	secret := []byte("super-secret-key")
+	token := buildToken(t, "HS256", func(signingInput string) []byte {
+		mac := hmac.New(jwtHash(HS256).New, secret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil)
+	})
+	config := JWTConfig{
+		SigningMethod: RS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return secret, nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted an HS256 token against an RS256-configured verifier")
+	}
+}
+
+func hs256Token(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	t.Helper()
+	return buildTokenWithClaims(t, "HS256", claims, func(signingInput string) []byte {
+		mac := hmac.New(jwtHash(HS256).New, secret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil)
+	})
+}
+
+func TestParseTokenRejectsExpiredToken(t *testing.T) {
+	secret := []byte("super-secret-key")
+	token := hs256Token(t, secret, map[string]interface{}{
+		"sub": "user-1",
+		"exp": time.Now().Add(-time.Minute).Unix(),
+	})
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return secret, nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted a token whose exp is in the past")
+	}
+}
+
+func TestParseTokenRejectsMissingExp(t *testing.T) {
+	secret := []byte("super-secret-key")
+	token := hs256Token(t, secret, map[string]interface{}{"sub": "user-1"})
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return secret, nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted a token with no exp claim")
+	}
+}
+
+func TestParseTokenRejectsNotYetValidToken(t *testing.T) {
+	secret := []byte("super-secret-key")
+	token := hs256Token(t, secret, map[string]interface{}{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(time.Minute).Unix(),
+	})
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return secret, nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted a token whose nbf is in the future")
+	}
+}
+
+func TestParseTokenLeewayToleratesClockSkew(t *testing.T) {
+	secret := []byte("super-secret-key")
+	token := hs256Token(t, secret, map[string]interface{}{
+		"sub": "user-1",
+		"exp": time.Now().Add(-10 * time.Second).Unix(),
+	})
+	config := JWTConfig{
+		SigningMethod: HS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return secret, nil },
+		Leeway:        time.Minute,
+	}
+	if _, err := ParseToken(token, config); err != nil {
+		t.Fatalf("ParseToken with a minute of leeway rejected a token only 10s past exp: %v", err)
+	}
+}
+
+func TestParseTokenRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	token := buildToken(t, "RS256", func(signingInput string) []byte {
+		hashed := jwtSum(jwtHash(RS256), signingInput)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, jwtHash(RS256), hashed)
+		if err != nil {
+			t.Fatalf("SignPKCS1v15: %v", err)
+		}
+		return sig
+	})
+	config := JWTConfig{
+		SigningMethod: RS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return &key.PublicKey, nil },
+	}
+	claims, err := ParseToken(token, config)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Fatalf("claims[sub] = %v, want user-1", claims["sub"])
+	}
+}
+
+func TestParseTokenRS256TamperedSignatureRejected(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	token := buildToken(t, "RS256", func(signingInput string) []byte {
+		hashed := jwtSum(jwtHash(RS256), signingInput)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, jwtHash(RS256), hashed)
+		if err != nil {
+			t.Fatalf("SignPKCS1v15: %v", err)
+		}
+		sig[0] ^= 1
+		return sig
+	})
+	config := JWTConfig{
+		SigningMethod: RS256,
+		KeyFunc:       func(Claims) (interface{}, error) { return &key.PublicKey, nil },
+	}
+	if _, err := ParseToken(token, config); err == nil {
+		t.Fatal("ParseToken accepted a tampered RS256 signature")
+	}
+}
+
+func TestValidateClaimRange(t *testing.T) {
+	claims := Claims{"age": float64(25), "name": "thinkgo"}
+	if !ValidateClaimRange(claims, "age", "18:65") {
+		t.Fatal("ValidateClaimRange rejected a value inside the range")
+	}
+	if ValidateClaimRange(claims, "age", "30:65") {
+		t.Fatal("ValidateClaimRange accepted a value below the range")
+	}
+	if ValidateClaimRange(claims, "age", "0:10") {
+		t.Fatal("ValidateClaimRange accepted a value above the range")
+	}
+	if ValidateClaimRange(claims, "missing", "0:10") {
+		t.Fatal("ValidateClaimRange accepted an absent claim")
+	}
+	if ValidateClaimRange(claims, "name", "0:10") {
+		t.Fatal("ValidateClaimRange accepted a non-numeric claim")
+	}
+	if !ValidateClaimRange(claims, "age", "65") {
+		t.Fatal("ValidateClaimRange rejected a bare max spec covering the value")
+	}
+}
+
+func TestValidateClaimLen(t *testing.T) {
+	claims := Claims{"sub": "user-1"}
+	if !ValidateClaimLen(claims, "sub", "3:10") {
+		t.Fatal("ValidateClaimLen rejected a string within the length range")
+	}
+	if ValidateClaimLen(claims, "sub", "0:3") {
+		t.Fatal("ValidateClaimLen accepted a string longer than the max")
+	}
+	if ValidateClaimLen(claims, "missing", "0:10") {
+		t.Fatal("ValidateClaimLen accepted an absent claim")
+	}
+}
+
+func TestValidateClaimRegexp(t *testing.T) {
+	claims := Claims{"sub": "user-1"}
+	if !ValidateClaimRegexp(claims, "sub", "^user-\\d+$") {
+		t.Fatal("ValidateClaimRegexp rejected a matching claim")
+	}
+	if ValidateClaimRegexp(claims, "sub", "^admin-\\d+$") {
+		t.Fatal("ValidateClaimRegexp accepted a non-matching claim")
+	}
+	if ValidateClaimRegexp(claims, "missing", "^.*$") {
+		t.Fatal("ValidateClaimRegexp accepted an absent claim")
+	}
+}
+
+func TestParseTokenES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	token := buildToken(t, "ES256", func(signingInput string) []byte {
+		hashed := jwtSum(jwtHash(ES256), signingInput)
+		r, s, err := ecdsa.Sign(rand.Reader, key, hashed)
+		if err != nil {
+			t.Fatalf("ecdsa.Sign: %v", err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig
+	})
+	config := JWTConfig{
+		SigningMethod: ES256,
+		KeyFunc:       func(Claims) (interface{}, error) { return &key.PublicKey, nil },
+	}
+	claims, err := ParseToken(token, config)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Fatalf("claims[sub] = %v, want user-1", claims["sub"])
+	}
+}
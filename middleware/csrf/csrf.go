@@ -0,0 +1,160 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csrf implements CSRF protection middleware for thinkgo using
+// the double-submit cookie pattern: a random token is issued in a
+// signed cookie on safe requests, and state-changing requests must
+// echo a matching token back via the configured header or form field.
+// A struct field tagged `param:"in(csrf)"` is not read: thinkgo's
+// struct-tag binder has no `in(csrf)` position. ValidateParam is
+// exported so code that obtains a token some other way can run the
+// same comparison.
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/henrylee2cn/thinkgo"
+)
+
+// errNoCookie indicates the request had no valid, unexpired token
+// cookie yet, so a new one should be issued.
+var errNoCookie = errors.New("thinkgo/csrf: no valid token cookie")
+
+// Config configures the CSRF middleware. The zero value is not
+// usable; use New, which fills in the defaults below.
+type Config struct {
+	// Secret signs the token cookie, the same way
+	// Context.SetSecureCookie does. Required.
+	Secret string
+	// CookieName names the cookie the token is stored in.
+	// Defaults to "_csrf".
+	CookieName string
+	// HeaderName names the request header state-changing requests
+	// may present the token in. Defaults to "X-CSRF-Token".
+	HeaderName string
+	// FormField names the form field state-changing requests may
+	// present the token in, for plain HTML form posts. Defaults to
+	// "_csrf".
+	FormField string
+	// TokenLength is the number of random bytes in a token, before
+	// base64 encoding. Defaults to 32.
+	TokenLength int
+	// CookieMaxAge is the token cookie's Max-Age in seconds.
+	// Defaults to 86400 (one day).
+	CookieMaxAge int
+	// TrustedOrigins, if non-empty, restricts validation to requests
+	// whose Origin header (when present) is in this list; requests
+	// from an untrusted Origin are rejected outright.
+	TrustedOrigins []string
+	// Skip, if non-nil, bypasses CSRF validation (but still issues a
+	// token cookie) for requests it returns true for, e.g. a
+	// `ctx.R.Header.Get("X-Requested-With") == "..."` carve-out.
+	Skip func(ctx *thinkgo.Context) bool
+}
+
+// New returns thinkgo middleware enforcing CSRF protection per config,
+// defaulting unset fields.
+func New(config Config) thinkgo.HandlerFunc {
+	if config.CookieName == "" {
+		config.CookieName = "_csrf"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.FormField == "" {
+		config.FormField = "_csrf"
+	}
+	if config.TokenLength <= 0 {
+		config.TokenLength = 32
+	}
+	if config.CookieMaxAge <= 0 {
+		config.CookieMaxAge = 86400
+	}
+	return func(ctx *thinkgo.Context) error {
+		token, err := tokenFromCookie(ctx, config)
+		if err != nil {
+			token, err = newToken(config.TokenLength)
+			if err != nil {
+				return err
+			}
+			ctx.SetSecureCookie(config.Secret, config.CookieName, token, config.CookieMaxAge, "/")
+		}
+		ctx.SetCSRFToken(token)
+
+		method := ctx.R.Method
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions || method == http.MethodTrace {
+			return ctx.Next()
+		}
+		if config.Skip != nil && config.Skip(ctx) {
+			return ctx.Next()
+		}
+		if len(config.TrustedOrigins) > 0 {
+			if origin := ctx.R.Header.Get("Origin"); origin != "" && !isTrustedOrigin(origin, config.TrustedOrigins) {
+				ctx.Error(http.StatusForbidden, "thinkgo/csrf: untrusted origin")
+				ctx.Stop()
+				return nil
+			}
+		}
+		provided := ctx.R.Header.Get(config.HeaderName)
+		if provided == "" {
+			provided = ctx.R.FormValue(config.FormField)
+		}
+		if !ValidateParam(ctx, provided) {
+			ctx.Error(http.StatusForbidden, "thinkgo/csrf: invalid or missing CSRF token")
+			ctx.Stop()
+			return nil
+		}
+		return ctx.Next()
+	}
+}
+
+// ValidateParam reports whether provided matches the token New issued
+// for ctx's request, in constant time. New calls it itself for the
+// header/form-field value it extracts; it is also the comparison a
+// struct-tag binder should make for a field tagged `param:"in(csrf)"`
+// once that position exists, so both paths enforce the token identically.
+func ValidateParam(ctx *thinkgo.Context, provided string) bool {
+	token := ctx.CSRFToken()
+	return token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func tokenFromCookie(ctx *thinkgo.Context, config Config) (string, error) {
+	token, ok := ctx.GetSecureCookie(config.Secret, config.CookieName)
+	if !ok {
+		return "", errNoCookie
+	}
+	return token, nil
+}
+
+func newToken(length int) (string, error) {
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(buf), nil
+}
+
+func isTrustedOrigin(origin string, trusted []string) bool {
+	for _, t := range trusted {
+		if t == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csrf
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+// ValidateParam's own constant-time comparison isn't covered here: it
+// takes a *thinkgo.Context, and constructing one requires thinkgo's
+// router/dispatch package, which is out of scope for this package's
+// tests. newToken and isTrustedOrigin are the parts of the token
+// compare path that don't need a live Context.
+
+func TestNewTokenLength(t *testing.T) {
+	token, err := newToken(32)
+	if err != nil {
+		t.Fatalf("newToken: %v", err)
+	}
+	decoded, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("decoding token: %v", err)
+	}
+	if len(decoded) != 32 {
+		t.Fatalf("newToken(32) decodes to %d bytes, want 32", len(decoded))
+	}
+}
+
+func TestNewTokenIsRandom(t *testing.T) {
+	a, err := newToken(32)
+	if err != nil {
+		t.Fatalf("newToken: %v", err)
+	}
+	b, err := newToken(32)
+	if err != nil {
+		t.Fatalf("newToken: %v", err)
+	}
+	if a == b {
+		t.Fatal("two calls to newToken produced the same token")
+	}
+}
+
+func TestIsTrustedOrigin(t *testing.T) {
+	trusted := []string{"https://example.com", "https://admin.example.com"}
+	if !isTrustedOrigin("https://example.com", trusted) {
+		t.Fatal("isTrustedOrigin rejected a trusted origin")
+	}
+	if isTrustedOrigin("https://evil.example.com", trusted) {
+		t.Fatal("isTrustedOrigin accepted an untrusted origin")
+	}
+	if isTrustedOrigin("https://example.com", nil) {
+		t.Fatal("isTrustedOrigin accepted an origin against an empty trust list")
+	}
+}
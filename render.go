@@ -0,0 +1,522 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResponseRenderer marshals data into bytes for a Content-Type, used
+// by Context.Render to implement content negotiation.
+type ResponseRenderer func(ctx *Context, data interface{}) ([]byte, error)
+
+// errNotAcceptable is returned by Context.Render when none of the
+// registered renderers can satisfy the request's Accept header.
+var errNotAcceptable = errors.New("thinkgo: no acceptable representation for response")
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]ResponseRenderer{
+		MIMEApplicationJSON: jsonRenderer,
+		MIMEApplicationXML:  xmlRenderer,
+		MIMETextHTML:        htmlRenderer,
+		MIMETextPlain:       plainRenderer,
+		MIMEMSGPACK:         msgpackRenderer,
+	}
+)
+
+// RegisterRenderer registers a ResponseRenderer for mime, overriding
+// any existing renderer (including the built-ins) for that type. It is
+// safe to call concurrently and is typically done once at init time,
+// e.g. thinkgo.RegisterRenderer("application/yaml", yamlRenderer).
+func RegisterRenderer(mime string, renderer ResponseRenderer) {
+	renderersMu.Lock()
+	renderers[mime] = renderer
+	renderersMu.Unlock()
+}
+
+func rendererFor(mime string) (ResponseRenderer, bool) {
+	renderersMu.RLock()
+	r, ok := renderers[mime]
+	renderersMu.RUnlock()
+	return r, ok
+}
+
+// Render picks a representation for data by negotiating the request's
+// Accept header against the registered renderers, writes status and
+// the negotiated Content-Type, and sends the marshaled body. If no
+// registered type is acceptable it responds 406 and returns
+// errNotAcceptable.
+func (ctx *Context) Render(status int, data interface{}) error {
+	offers := make([]string, 0, len(renderers))
+	renderersMu.RLock()
+	for mime := range renderers {
+		offers = append(offers, mime)
+	}
+	renderersMu.RUnlock()
+	// deterministic fallback order when the client sends no Accept
+	// header or "*/*": prefer JSON, thinkgo's historical default.
+	sort.Slice(offers, func(i, j int) bool {
+		if offers[i] == MIMEApplicationJSON {
+			return true
+		}
+		if offers[j] == MIMEApplicationJSON {
+			return false
+		}
+		return offers[i] < offers[j]
+	})
+	mime := ctx.NegotiateContentType(offers)
+	renderer, ok := rendererFor(mime)
+	if mime == "" || !ok {
+		ctx.Error(406, "thinkgo: no acceptable representation for response")
+		return errNotAcceptable
+	}
+	b, err := renderer(ctx, data)
+	if err != nil {
+		return err
+	}
+	ctx.W.Header().Set(HeaderContentType, mime)
+	return ctx.Send(status, b)
+}
+
+// NegotiateContentType returns the offer that best satisfies the
+// request's Accept header, following RFC 7231 §5.3.2 quality-value
+// negotiation: offers are scored by the best-matching Accept range
+// (exact type/subtype with matching parameters, exact type/subtype,
+// subtype wildcard, full wildcard), ties broken by the Accept entry's
+// parameter specificity, and entries with q=0 are excluded. An Accept
+// entry's parameters (other than q and any accept-ext following it)
+// must all match an offer's own parameters for that entry to match it
+// at all, per RFC 7231 §5.3.2's "parameters" handling; an offer may
+// carry parameters itself, e.g. "application/vnd.thinkgo+json;version=2".
+// It returns "" if no offer is acceptable.
+func (ctx *Context) NegotiateContentType(offers []string) string {
+	accept := ctx.R.Header.Get(HeaderAccept)
+	if accept == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return ""
+	}
+	ranges := parseAccept(accept)
+	best := ""
+	bestQ := 0.0
+	bestSpecificity := -1
+	for _, offer := range offers {
+		offerType, offerSub, offerParams := splitOfferMIME(offer)
+		q, specificity := 0.0, -1
+		for _, rg := range ranges {
+			s, ok := matchRange(offerType, offerSub, offerParams, rg)
+			if ok && s > specificity {
+				q, specificity = rg.q, s
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+type acceptRange struct {
+	typ, sub string
+	q        float64
+	params   map[string]string
+}
+
+// parseAccept parses an Accept header value into its media ranges,
+// each with its quality value (defaulting to 1) and its media-type
+// parameters (everything before "q=": RFC 7231 accept-ext parameters
+// that follow q apply to the Accept entry as a whole, not the media
+// type, so they aren't part of the match).
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		segments := strings.Split(part, ";")
+		mime := strings.TrimSpace(segments[0])
+		typ, sub := splitMIME(mime)
+		q := 1.0
+		params := make(map[string]string)
+		for _, p := range segments[1:] {
+			key, value := splitParam(p)
+			if key == "" {
+				continue
+			}
+			if key == "q" {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+				break
+			}
+			params[key] = value
+		}
+		ranges = append(ranges, acceptRange{typ: typ, sub: sub, q: q, params: params})
+	}
+	return ranges
+}
+
+func splitMIME(mime string) (typ, sub string) {
+	parts := strings.SplitN(mime, "/", 2)
+	if len(parts) != 2 {
+		return strings.TrimSpace(mime), "*"
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+// splitOfferMIME splits an offer (a renderer's registered MIME, which
+// may itself carry parameters, e.g. "application/vnd.thinkgo+json;version=2")
+// into its type, subtype and parameters, the same shape parseAccept
+// produces for an Accept entry so matchRange can compare them.
+func splitOfferMIME(offer string) (typ, sub string, params map[string]string) {
+	segments := strings.Split(offer, ";")
+	typ, sub = splitMIME(segments[0])
+	params = make(map[string]string)
+	for _, p := range segments[1:] {
+		key, value := splitParam(p)
+		if key != "" {
+			params[key] = value
+		}
+	}
+	return typ, sub, params
+}
+
+// splitParam parses a single ";"-separated Accept/offer segment of the
+// form ` name=value ` or ` name="value" ` into a lowercased key and
+// its value, or ("", "") if it isn't a key=value pair.
+func splitParam(p string) (key, value string) {
+	p = strings.TrimSpace(p)
+	i := strings.IndexByte(p, '=')
+	if i < 0 {
+		return "", ""
+	}
+	key = strings.ToLower(strings.TrimSpace(p[:i]))
+	value = strings.Trim(strings.TrimSpace(p[i+1:]), `"`)
+	return key, value
+}
+
+// matchRange reports whether an offer's type/subtype/parameters is
+// covered by an Accept media range, and how specific the match is (3 =
+// exact type/subtype with all of the range's parameters matching the
+// offer's, 2 = exact type/subtype with no range parameters to check, 1
+// = type match with subtype wildcard, 0 = full wildcard) so the best
+// of several matching ranges can be chosen. A range that names
+// parameters the offer doesn't also present (or disagrees with) is not
+// a match at all, per RFC 7231 §5.3.2: parameters make a media range
+// more specific, not optional.
+func matchRange(offerType, offerSub string, offerParams map[string]string, rg acceptRange) (int, bool) {
+	switch {
+	case rg.typ == "*" && rg.sub == "*":
+		if len(rg.params) > 0 {
+			return 0, false
+		}
+		return 0, true
+	case rg.typ == offerType && rg.sub == "*":
+		if len(rg.params) > 0 {
+			return 0, false
+		}
+		return 1, true
+	case rg.typ == offerType && rg.sub == offerSub:
+		for k, v := range rg.params {
+			if offerParams[k] != v {
+				return 0, false
+			}
+		}
+		if len(rg.params) > 0 {
+			return 3, true
+		}
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+func jsonRenderer(ctx *Context, data interface{}) ([]byte, error) {
+	if ctx.frame.config.RunMode == RUNMODE_PROD {
+		return json.Marshal(data)
+	}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+func xmlRenderer(ctx *Context, data interface{}) ([]byte, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if ctx.frame.config.RunMode == RUNMODE_PROD {
+		b, err = xml.Marshal(data)
+	} else {
+		b, err = xml.MarshalIndent(data, "", "  ")
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(xml.Header)+len(b))
+	out = append(out, xml.Header...)
+	return append(out, b...), nil
+}
+
+// TemplateData pairs an html/template name with the data to execute it
+// with. Pass a TemplateData to Context.Render to have the built-in HTML
+// renderer execute it against the template set registered via
+// SetHTMLTemplates.
+type TemplateData struct {
+	Name string
+	Data interface{}
+}
+
+var (
+	htmlTemplatesMu sync.RWMutex
+	htmlTemplates   *template.Template
+)
+
+// SetHTMLTemplates registers the *template.Template set the built-in
+// HTML renderer executes TemplateData against, e.g. one built with
+// template.ParseGlob at startup.
+func SetHTMLTemplates(t *template.Template) {
+	htmlTemplatesMu.Lock()
+	htmlTemplates = t
+	htmlTemplatesMu.Unlock()
+}
+
+func htmlRenderer(ctx *Context, data interface{}) ([]byte, error) {
+	switch v := data.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case TemplateData:
+		htmlTemplatesMu.RLock()
+		t := htmlTemplates
+		htmlTemplatesMu.RUnlock()
+		if t == nil {
+			return nil, errors.New("thinkgo: no HTML templates registered, call thinkgo.SetHTMLTemplates first")
+		}
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, v.Name, v.Data); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case fmt.Stringer:
+		return []byte(v.String()), nil
+	default:
+		return nil, fmt.Errorf("thinkgo: %T is not renderable as %s, pass a string, []byte, fmt.Stringer or thinkgo.TemplateData", data, MIMETextHTML)
+	}
+}
+
+func plainRenderer(ctx *Context, data interface{}) ([]byte, error) {
+	switch v := data.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case fmt.Stringer:
+		return []byte(v.String()), nil
+	default:
+		return []byte(fmt.Sprint(data)), nil
+	}
+}
+
+// msgpackRenderer encodes data as MessagePack (https://msgpack.org).
+// It round-trips data through encoding/json into generic
+// map[string]interface{}/[]interface{}/string/json.Number/bool/nil
+// values first, the same values any of those types would decode to
+// with UseNumber enabled, so struct tags and embedding are honored the
+// same way the JSON renderer honors them, then walks that generic
+// value to write MessagePack bytes directly, without a third-party
+// codec dependency. Decoding numbers as json.Number instead of the
+// default float64 is what lets encodeMsgpack tell an originally
+// integral Go value (an int/int64 ID or count field, say) from a
+// genuine float and encode it with msgpack's int formats instead of
+// always emitting a 64-bit float, which would lose precision above
+// 2^53 and confuse strictly-typed msgpack decoders expecting an int.
+// A float whose value is integral (e.g. 3.0) marshals to the same JSON
+// text as an int and is therefore encoded as an int as well.
+func msgpackRenderer(ctx *Context, data interface{}) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeMsgpack(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeMsgpack(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if val {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			writeMsgpackInt(buf, i)
+			return nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return err
+		}
+		writeMsgpackFloat64(buf, f)
+	case float64:
+		writeMsgpackFloat64(buf, val)
+	case string:
+		writeMsgpackString(buf, val)
+	case []interface{}:
+		writeMsgpackArrayHeader(buf, len(val))
+		for _, e := range val {
+			if err := encodeMsgpack(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeMsgpackMapHeader(buf, len(val))
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeMsgpackString(buf, k)
+			if err := encodeMsgpack(buf, val[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("thinkgo: cannot encode %T as msgpack", v)
+	}
+	return nil
+}
+
+func writeMsgpackFloat64(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xcb)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+	buf.Write(b[:])
+}
+
+// writeMsgpackInt writes v using the narrowest msgpack int format that
+// can hold it: positive/negative fixint, then int8/16/32/64.
+func writeMsgpackInt(buf *bytes.Buffer, v int64) {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		buf.WriteByte(byte(v))
+	case v < 0 && v >= -32:
+		buf.WriteByte(0xe0 | byte(v+32))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(v))
+		buf.Write(b[:])
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(v))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(0xd3)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		buf.Write(b[:])
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n < 1<<8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xda)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(n))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(0xdb)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		buf.Write(b[:])
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgpackArrayHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xdc)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(n))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(0xdd)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		buf.Write(b[:])
+	}
+}
+
+func writeMsgpackMapHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xde)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(n))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(0xdf)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		buf.Write(b[:])
+	}
+}
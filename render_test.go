@@ -0,0 +1,284 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestParseAcceptQValues(t *testing.T) {
+	ranges := parseAccept("text/html;q=0.8, application/json, application/xml;q=0.1")
+	if len(ranges) != 3 {
+		t.Fatalf("parseAccept returned %d ranges, want 3", len(ranges))
+	}
+	if ranges[0].typ != "text" || ranges[0].sub != "html" || ranges[0].q != 0.8 {
+		t.Fatalf("ranges[0] = %+v, want text/html q=0.8", ranges[0])
+	}
+	if ranges[1].typ != "application" || ranges[1].sub != "json" || ranges[1].q != 1 {
+		t.Fatalf("ranges[1] = %+v, want application/json q=1 (default)", ranges[1])
+	}
+	if ranges[2].q != 0.1 {
+		t.Fatalf("ranges[2].q = %v, want 0.1", ranges[2].q)
+	}
+}
+
+func TestMatchRangeWildcards(t *testing.T) {
+	cases := []struct {
+		name            string
+		rg              acceptRange
+		wantOK          bool
+		wantSpecificity int
+	}{
+		{"full wildcard", acceptRange{typ: "*", sub: "*", q: 1}, true, 0},
+		{"subtype wildcard", acceptRange{typ: "application", sub: "*", q: 1}, true, 1},
+		{"exact match", acceptRange{typ: "application", sub: "json", q: 1}, true, 2},
+		{"type mismatch", acceptRange{typ: "text", sub: "*", q: 1}, false, 0},
+		{"subtype mismatch", acceptRange{typ: "application", sub: "xml", q: 1}, false, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			specificity, ok := matchRange("application", "json", map[string]string{}, c.rg)
+			if ok != c.wantOK {
+				t.Fatalf("matchRange ok = %v, want %v", ok, c.wantOK)
+			}
+			if ok && specificity != c.wantSpecificity {
+				t.Fatalf("matchRange specificity = %d, want %d", specificity, c.wantSpecificity)
+			}
+		})
+	}
+}
+
+func TestMatchRangeParameters(t *testing.T) {
+	offerParams := map[string]string{"version": "2"}
+
+	specificity, ok := matchRange("application", "json", offerParams, acceptRange{
+		typ: "application", sub: "json", q: 1, params: map[string]string{"version": "2"},
+	})
+	if !ok || specificity != 3 {
+		t.Fatalf("matching parameter: got (%d, %v), want (3, true)", specificity, ok)
+	}
+
+	_, ok = matchRange("application", "json", offerParams, acceptRange{
+		typ: "application", sub: "json", q: 1, params: map[string]string{"version": "3"},
+	})
+	if ok {
+		t.Fatal("mismatched parameter value should not match")
+	}
+
+	_, ok = matchRange("application", "json", map[string]string{}, acceptRange{
+		typ: "application", sub: "json", q: 1, params: map[string]string{"version": "2"},
+	})
+	if ok {
+		t.Fatal("range parameter absent from the offer should not match")
+	}
+}
+
+func TestEncodeMsgpackRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	value := map[string]interface{}{
+		"name":   "thinkgo",
+		"count":  float64(3),
+		"active": true,
+		"tags":   []interface{}{"a", "b"},
+		"nested": nil,
+	}
+	if err := encodeMsgpack(&buf, value); err != nil {
+		t.Fatalf("encodeMsgpack: %v", err)
+	}
+	decoded, rest, err := decodeMsgpackForTest(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decodeMsgpackForTest: %v", err)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("%d trailing bytes after decoding", len(rest))
+	}
+	decodedMap, ok := decoded.(map[string]interface{})
+	if !ok {
+		t.Fatalf("decoded = %T, want map[string]interface{}", decoded)
+	}
+	if decodedMap["name"] != "thinkgo" || decodedMap["count"] != float64(3) || decodedMap["active"] != true {
+		t.Fatalf("decoded = %+v, want round-tripped scalar fields", decodedMap)
+	}
+	tags, ok := decodedMap["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Fatalf("decoded tags = %v, want [a b]", decodedMap["tags"])
+	}
+	if decodedMap["nested"] != nil {
+		t.Fatalf("decoded nested = %v, want nil", decodedMap["nested"])
+	}
+}
+
+// decodeMsgpackForTest decodes just enough of the subset of MessagePack
+// encodeMsgpack produces (nil, bool, int, float64 double, fixstr, fixarray,
+// fixmap) to
+// verify its round trip; it isn't a general-purpose decoder.
+func decodeMsgpackForTest(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errShortMsgpack
+	}
+	tag := b[0]
+	switch {
+	case tag == 0xc0:
+		return nil, b[1:], nil
+	case tag == 0xc2:
+		return false, b[1:], nil
+	case tag == 0xc3:
+		return true, b[1:], nil
+	case tag == 0xcb:
+		if len(b) < 9 {
+			return nil, nil, errShortMsgpack
+		}
+		bits := binary.BigEndian.Uint64(b[1:9])
+		return math.Float64frombits(bits), b[9:], nil
+	case tag <= 0x7f:
+		return int64(tag), b[1:], nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), b[1:], nil
+	case tag == 0xd0:
+		if len(b) < 2 {
+			return nil, nil, errShortMsgpack
+		}
+		return int64(int8(b[1])), b[2:], nil
+	case tag == 0xd1:
+		if len(b) < 3 {
+			return nil, nil, errShortMsgpack
+		}
+		return int64(int16(binary.BigEndian.Uint16(b[1:3]))), b[3:], nil
+	case tag == 0xd2:
+		if len(b) < 5 {
+			return nil, nil, errShortMsgpack
+		}
+		return int64(int32(binary.BigEndian.Uint32(b[1:5]))), b[5:], nil
+	case tag == 0xd3:
+		if len(b) < 9 {
+			return nil, nil, errShortMsgpack
+		}
+		return int64(binary.BigEndian.Uint64(b[1:9])), b[9:], nil
+	case tag&0xe0 == 0xa0:
+		n := int(tag & 0x1f)
+		return string(b[1 : 1+n]), b[1+n:], nil
+	case tag&0xf0 == 0x90:
+		n := int(tag & 0x0f)
+		return decodeMsgpackArray(b[1:], n)
+	case tag&0xf0 == 0x80:
+		n := int(tag & 0x0f)
+		return decodeMsgpackMap(b[1:], n)
+	default:
+		return nil, nil, errUnsupportedMsgpackTag
+	}
+}
+
+var (
+	errShortMsgpack          = fmt.Errorf("thinkgo: truncated msgpack test fixture")
+	errUnsupportedMsgpackTag = fmt.Errorf("thinkgo: unsupported msgpack tag in test fixture")
+)
+
+func decodeMsgpackArray(b []byte, n int) (interface{}, []byte, error) {
+	out := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, rest, err := decodeMsgpackForTest(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, v)
+		b = rest
+	}
+	return out, b, nil
+}
+
+func decodeMsgpackMap(b []byte, n int) (interface{}, []byte, error) {
+	out := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, rest, err := decodeMsgpackForTest(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, nil, errUnsupportedMsgpackTag
+		}
+		b = rest
+		v, rest, err := decodeMsgpackForTest(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		out[key] = v
+		b = rest
+	}
+	return out, b, nil
+}
+
+// TestMsgpackRendererEncodesIntsAsInts guards against msgpackRenderer's
+// json.Marshal/json.Decode detour losing a field's int-ness: feeding a
+// real Go int (as opposed to building a float64 by hand and passing it
+// straight to encodeMsgpack, which can't exercise this bug) must
+// produce a msgpack int tag, not the 0xcb float64 tag.
+func TestMsgpackRendererEncodesIntsAsInts(t *testing.T) {
+	b, err := msgpackRenderer(nil, map[string]interface{}{"count": 3})
+	if err != nil {
+		t.Fatalf("msgpackRenderer: %v", err)
+	}
+	decoded, rest, err := decodeMsgpackForTest(b)
+	if err != nil {
+		t.Fatalf("decodeMsgpackForTest: %v", err)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("%d trailing bytes after decoding", len(rest))
+	}
+	decodedMap, ok := decoded.(map[string]interface{})
+	if !ok {
+		t.Fatalf("decoded = %T, want map[string]interface{}", decoded)
+	}
+	if decodedMap["count"] != int64(3) {
+		t.Fatalf("decoded count = %#v (%T), want int64(3) encoded via a msgpack int tag, not a float", decodedMap["count"], decodedMap["count"])
+	}
+}
+
+func TestWriteMsgpackIntPicksNarrowestFormat(t *testing.T) {
+	cases := []struct {
+		v        int64
+		wantByte byte
+	}{
+		{0, 0x00},
+		{0x7f, 0x7f},
+		{-1, 0xff},
+		{-32, 0xe0},
+		{-33, 0xd0},
+		{math.MaxInt8 + 1, 0xd1},
+		{math.MaxInt16 + 1, 0xd2},
+		{math.MaxInt32 + 1, 0xd3},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		writeMsgpackInt(&buf, c.v)
+		if got := buf.Bytes()[0]; got != c.wantByte {
+			t.Fatalf("writeMsgpackInt(%d) tag = %#x, want %#x", c.v, got, c.wantByte)
+		}
+	}
+}
+
+func TestSplitOfferMIME(t *testing.T) {
+	typ, sub, params := splitOfferMIME("application/vnd.thinkgo+json;version=2")
+	if typ != "application" || sub != "vnd.thinkgo+json" {
+		t.Fatalf("splitOfferMIME type/sub = %q/%q, want application/vnd.thinkgo+json", typ, sub)
+	}
+	if params["version"] != "2" {
+		t.Fatalf("splitOfferMIME params = %v, want version=2", params)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import "testing"
+
+func TestGCMCodecRoundTrip(t *testing.T) {
+	codec, err := NewGCMCodec([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewGCMCodec: %v", err)
+	}
+	values := map[interface{}]interface{}{"userID": 42, "flash": "welcome back"}
+	encoded, err := codec.Encode(values)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := codec.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded["userID"] != 42 || decoded["flash"] != "welcome back" {
+		t.Fatalf("Decode = %v, want round-tripped values", decoded)
+	}
+}
+
+func TestGCMCodecRejectsTamperedCiphertext(t *testing.T) {
+	codec, err := NewGCMCodec([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewGCMCodec: %v", err)
+	}
+	encoded, err := codec.Encode(map[interface{}]interface{}{"userID": 42})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	tampered := []byte(encoded)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := codec.Decode(string(tampered)); err == nil {
+		t.Fatal("Decode succeeded on tampered ciphertext, want error")
+	}
+}
+
+func TestGCMCodecRejectsWrongKey(t *testing.T) {
+	codec, err := NewGCMCodec([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewGCMCodec: %v", err)
+	}
+	encoded, err := codec.Encode(map[interface{}]interface{}{"userID": 42})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	other, err := NewGCMCodec([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("NewGCMCodec: %v", err)
+	}
+	if _, err := other.Decode(encoded); err == nil {
+		t.Fatal("Decode succeeded with the wrong key, want error")
+	}
+}
+
+func TestCodecRingKeyRotation(t *testing.T) {
+	oldCodec, err := NewGCMCodec([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewGCMCodec: %v", err)
+	}
+	newCodec, err := NewGCMCodec([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("NewGCMCodec: %v", err)
+	}
+
+	oldEncoded, err := oldCodec.Encode(map[interface{}]interface{}{"userID": 7})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	ring := CodecRing{newCodec, oldCodec}
+	decoded, err := ring.Decode(oldEncoded)
+	if err != nil {
+		t.Fatalf("Decode of a cookie sealed with the rotated-out key: %v", err)
+	}
+	if decoded["userID"] != 7 {
+		t.Fatalf("Decode = %v, want userID=7", decoded)
+	}
+
+	newEncoded, err := ring.Encode(map[interface{}]interface{}{"userID": 8})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if _, err := oldCodec.Decode(newEncoded); err == nil {
+		t.Fatal("new cookies should be sealed with the newest codec, but the old codec could decode one")
+	}
+}
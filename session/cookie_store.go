@@ -0,0 +1,108 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"net/http"
+)
+
+// CookieStore stores the whole Session, authenticated-encrypted, in
+// the cookie itself, so no server-side storage is required.
+type CookieStore struct {
+	Codecs  CodecRing
+	Options *Options
+}
+
+// NewCookieStore returns a new CookieStore. Each key in keys becomes a
+// GCMCodec; the first key is always used to seal new cookies, while
+// the rest are tried in order when opening one, which lets keys be
+// rotated without invalidating sessions sealed under an older key.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	cs := &CookieStore{
+		Options: &Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+	for _, key := range keys {
+		codec, err := NewGCMCodec(key)
+		if err != nil {
+			return nil, err
+		}
+		cs.Codecs = append(cs.Codecs, codec)
+	}
+	return cs, nil
+}
+
+// Get returns the named session for r, loading and caching it on the
+// request so repeated calls within the same request see the same
+// instance.
+func (cs *CookieStore) Get(r *http.Request, name string) (*Session, error) {
+	reg := GetRegistry(r)
+	return reg.Get(cs, name)
+}
+
+// New always returns a new Session, populated from r's cookie named
+// name if present and valid.
+func (cs *CookieStore) New(r *http.Request, name string) (*Session, error) {
+	s := NewSession(cs, name)
+	s.Options = &Options{
+		Path:     cs.Options.Path,
+		Domain:   cs.Options.Domain,
+		MaxAge:   cs.Options.MaxAge,
+		Secure:   cs.Options.Secure,
+		HttpOnly: cs.Options.HttpOnly,
+		SameSite: cs.Options.SameSite,
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	values, err := cs.Codecs.Decode(cookie.Value)
+	if err != nil {
+		// an invalid or tampered cookie yields a fresh session
+		// rather than an error, matching the permissive behaviour of
+		// Context.GetSecureCookie.
+		return s, nil
+	}
+	s.Values = values
+	s.IsNew = false
+	return s, nil
+}
+
+// Save seals s.Values and writes it to w as a Set-Cookie header.
+func (cs *CookieStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	encoded, err := cs.Codecs.Encode(s.Values)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, newCookie(s.name, encoded, s.Options))
+	return nil
+}
+
+// newCookie builds an *http.Cookie from session options.
+func newCookie(name, value string, opts *Options) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   opts.MaxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	}
+	return c
+}
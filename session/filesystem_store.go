@@ -0,0 +1,150 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilesystemStore stores session values in files under Dir, keyed by
+// a random session ID; only the ID (authenticated-encrypted the same
+// way as CookieStore) travels in the cookie.
+type FilesystemStore struct {
+	Codecs  CodecRing
+	Options *Options
+	Dir     string
+}
+
+// NewFilesystemStore returns a FilesystemStore that writes session
+// files into dir (created if necessary), signing/encrypting the
+// cookie-carried session ID with keys the same way NewCookieStore
+// does.
+func NewFilesystemStore(dir string, keys ...[]byte) (*FilesystemStore, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	fs := &FilesystemStore{
+		Dir: dir,
+		Options: &Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+	for _, key := range keys {
+		codec, err := NewGCMCodec(key)
+		if err != nil {
+			return nil, err
+		}
+		fs.Codecs = append(fs.Codecs, codec)
+	}
+	return fs, nil
+}
+
+// Get returns the named session for r, loading and caching it on the
+// request.
+func (fs *FilesystemStore) Get(r *http.Request, name string) (*Session, error) {
+	reg := GetRegistry(r)
+	return reg.Get(fs, name)
+}
+
+// New always returns a new Session, populated from the file named by
+// r's cookie if the cookie decodes to a known session ID.
+func (fs *FilesystemStore) New(r *http.Request, name string) (*Session, error) {
+	s := NewSession(fs, name)
+	s.Options = &Options{
+		Path:     fs.Options.Path,
+		Domain:   fs.Options.Domain,
+		MaxAge:   fs.Options.MaxAge,
+		Secure:   fs.Options.Secure,
+		HttpOnly: fs.Options.HttpOnly,
+		SameSite: fs.Options.SameSite,
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	idValues, err := fs.Codecs.Decode(cookie.Value)
+	if err != nil {
+		return s, nil
+	}
+	id, _ := idValues["id"].(string)
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return s, nil
+	}
+	f, err := os.Open(fs.filePath(id))
+	if err != nil {
+		return s, nil
+	}
+	defer f.Close()
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(f).Decode(&values); err != nil {
+		return s, nil
+	}
+	s.Values = values
+	s.IsNew = false
+	s.id = id
+	return s, nil
+}
+
+// Save writes s.Values to its backing file, generating a new session
+// ID on first save, and seals that ID into the response cookie.
+func (fs *FilesystemStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if len(fs.Codecs) == 0 {
+		return errors.New("session: no codecs configured for FilesystemStore")
+	}
+	if s.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		s.id = id
+	}
+	f, err := os.Create(fs.filePath(s.id))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := gob.NewEncoder(f).Encode(s.Values); err != nil {
+		return err
+	}
+	encoded, err := fs.Codecs.Encode(map[interface{}]interface{}{"id": s.id})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, newCookie(s.name, encoded, s.Options))
+	return nil
+}
+
+func (fs *FilesystemStore) filePath(id string) string {
+	return filepath.Join(fs.Dir, "session_"+id)
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"net/http"
+	"sync"
+)
+
+// MemStore keeps sessions in an in-process map, keyed by a random
+// session ID carried in a plain (unsigned) cookie. It is intended for
+// tests and local development only, never for production use, since
+// sessions neither survive a restart nor are shared across processes.
+type MemStore struct {
+	Options *Options
+
+	mu   sync.Mutex
+	data map[string]map[interface{}]interface{}
+}
+
+// NewMemStore returns a ready-to-use MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		Options: &Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+		data: make(map[string]map[interface{}]interface{}),
+	}
+}
+
+// Get returns the named session for r, loading and caching it on the
+// request.
+func (ms *MemStore) Get(r *http.Request, name string) (*Session, error) {
+	reg := GetRegistry(r)
+	return reg.Get(ms, name)
+}
+
+// New always returns a new Session, populated from the in-memory map
+// if r's cookie names a known session ID.
+func (ms *MemStore) New(r *http.Request, name string) (*Session, error) {
+	s := NewSession(ms, name)
+	s.Options = &Options{
+		Path:     ms.Options.Path,
+		Domain:   ms.Options.Domain,
+		MaxAge:   ms.Options.MaxAge,
+		Secure:   ms.Options.Secure,
+		HttpOnly: ms.Options.HttpOnly,
+		SameSite: ms.Options.SameSite,
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	ms.mu.Lock()
+	values, ok := ms.data[cookie.Value]
+	ms.mu.Unlock()
+	if !ok {
+		return s, nil
+	}
+	s.Values = values
+	s.IsNew = false
+	s.id = cookie.Value
+	return s, nil
+}
+
+// Save stores s.Values in the in-memory map, generating a new session
+// ID on first save, and writes that ID as the response cookie.
+func (ms *MemStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if s.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		s.id = id
+	}
+	ms.mu.Lock()
+	ms.data[s.id] = s.Values
+	ms.mu.Unlock()
+	http.SetCookie(w, newCookie(s.name, s.id, s.Options))
+	return nil
+}
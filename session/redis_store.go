@@ -0,0 +1,146 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Conn is the minimal redis client surface RedisStore depends on, so
+// this package does not hard-require a particular redis driver; wrap
+// whichever client the application already uses (e.g. redigo, go-redis)
+// to satisfy it.
+type Conn interface {
+	// Get returns the raw value stored at key, or a nil slice if key
+	// does not exist.
+	Get(key string) ([]byte, error)
+	// Set stores value at key with the given expiration (0 means no
+	// expiration).
+	Set(key string, value []byte, expire time.Duration) error
+	// Del removes key.
+	Del(key string) error
+}
+
+// RedisStore stores session values in redis, keyed by a random
+// session ID; only the ID (authenticated-encrypted like CookieStore)
+// travels in the cookie.
+type RedisStore struct {
+	Conn    Conn
+	Codecs  CodecRing
+	Options *Options
+	// KeyPrefix is prepended to the session ID to form the redis key.
+	KeyPrefix string
+}
+
+// NewRedisStore returns a RedisStore backed by conn.
+func NewRedisStore(conn Conn, keys ...[]byte) (*RedisStore, error) {
+	rs := &RedisStore{
+		Conn:      conn,
+		KeyPrefix: "session:",
+		Options: &Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+	for _, key := range keys {
+		codec, err := NewGCMCodec(key)
+		if err != nil {
+			return nil, err
+		}
+		rs.Codecs = append(rs.Codecs, codec)
+	}
+	return rs, nil
+}
+
+// Get returns the named session for r, loading and caching it on the
+// request.
+func (rs *RedisStore) Get(r *http.Request, name string) (*Session, error) {
+	reg := GetRegistry(r)
+	return reg.Get(rs, name)
+}
+
+// New always returns a new Session, populated from redis if r's
+// cookie decodes to a known session ID.
+func (rs *RedisStore) New(r *http.Request, name string) (*Session, error) {
+	s := NewSession(rs, name)
+	s.Options = &Options{
+		Path:     rs.Options.Path,
+		Domain:   rs.Options.Domain,
+		MaxAge:   rs.Options.MaxAge,
+		Secure:   rs.Options.Secure,
+		HttpOnly: rs.Options.HttpOnly,
+		SameSite: rs.Options.SameSite,
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	idValues, err := rs.Codecs.Decode(cookie.Value)
+	if err != nil {
+		return s, nil
+	}
+	id, _ := idValues["id"].(string)
+	if id == "" {
+		return s, nil
+	}
+	raw, err := rs.Conn.Get(rs.KeyPrefix + id)
+	if err != nil || raw == nil {
+		return s, nil
+	}
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&values); err != nil {
+		return s, nil
+	}
+	s.Values = values
+	s.IsNew = false
+	s.id = id
+	return s, nil
+}
+
+// Save writes s.Values to redis, generating a new session ID on first
+// save, and seals that ID into the response cookie.
+func (rs *RedisStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if len(rs.Codecs) == 0 {
+		return errors.New("session: no codecs configured for RedisStore")
+	}
+	if s.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		s.id = id
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.Values); err != nil {
+		return err
+	}
+	expire := time.Duration(s.Options.MaxAge) * time.Second
+	if s.Options.MaxAge <= 0 {
+		expire = 0
+	}
+	if err := rs.Conn.Set(rs.KeyPrefix+s.id, buf.Bytes(), expire); err != nil {
+		return err
+	}
+	encoded, err := rs.Codecs.Encode(map[interface{}]interface{}{"id": s.id})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, newCookie(s.name, encoded, s.Options))
+	return nil
+}
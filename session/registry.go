@@ -0,0 +1,87 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type contextKey int
+
+// registryKey is the request-context key under which a Registry is
+// stashed once one has been created for a request.
+const registryKey contextKey = 0
+
+type sessionInfo struct {
+	s   *Session
+	err error
+}
+
+// Registry tracks the sessions loaded for a single request, so that a
+// named session is only fetched from its Store once per request, and
+// so that every session touched during the request can be committed
+// together when the response is written.
+type Registry struct {
+	request  *http.Request
+	sessions map[string]sessionInfo
+}
+
+// GetRegistry returns the Registry carried by r's context, creating
+// one on first call. Like gorilla/sessions, it mutates *r in place
+// (via r.WithContext) to attach the new Registry rather than handing
+// back a new *http.Request, so every caller holding the same r
+// pointer — including a Store.Get implementation invoked directly by
+// application code, not just through thinkgo.WithSessions — observes
+// and shares the same Registry for the rest of the request.
+func GetRegistry(r *http.Request) *Registry {
+	ctx := r.Context()
+	if reg, ok := ctx.Value(registryKey).(*Registry); ok {
+		return reg
+	}
+	reg := &Registry{request: r, sessions: make(map[string]sessionInfo)}
+	*r = *r.WithContext(context.WithValue(ctx, registryKey, reg))
+	return reg
+}
+
+// Get returns the named session, loading it from store on first
+// access within the request and returning the cached instance on
+// subsequent calls.
+func (reg *Registry) Get(store Store, name string) (*Session, error) {
+	if info, ok := reg.sessions[name]; ok {
+		return info.s, info.err
+	}
+	s, err := store.New(reg.request, name)
+	if s != nil {
+		s.name = name
+	}
+	reg.sessions[name] = sessionInfo{s: s, err: err}
+	return s, err
+}
+
+// Save commits every session loaded through the registry by calling
+// its Store's Save method.
+func (reg *Registry) Save(w http.ResponseWriter) error {
+	for name, info := range reg.sessions {
+		if info.s == nil {
+			continue
+		}
+		if err := info.s.store.Save(reg.request, w, info.s); err != nil {
+			return fmt.Errorf("session: error saving session %q: %s", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package session implements a pluggable session management subsystem
+// for thinkgo, modeled after gorilla/sessions: a Store interface with
+// backend implementations (CookieStore, FilesystemStore, RedisStore,
+// MemStore) plus a Session type carrying arbitrary values and flash
+// messages. See thinkgo's Context.Session for the framework-level
+// accessor that wires this package into the request lifecycle.
+package session
+
+import (
+	"net/http"
+)
+
+// Store is the interface implemented by session backends such as
+// CookieStore, FilesystemStore, RedisStore and MemStore.
+type Store interface {
+	// Get should return a cached session, or create and return a new
+	// one, for the given request and session name.
+	Get(r *http.Request, name string) (*Session, error)
+
+	// New always returns a new Session for the given request and
+	// name, populating it from the backend if a matching session
+	// already exists. Unlike Get it never returns a session cached on
+	// the request.
+	New(r *http.Request, name string) (*Session, error)
+
+	// Save persists the Session, writing any response-side state
+	// (e.g. a Set-Cookie header) via w.
+	Save(r *http.Request, w http.ResponseWriter, s *Session) error
+}
+
+// Session carries per-client state between requests. Values are
+// opaque to the framework and are serialized by the Store
+// implementation that created the Session.
+type Session struct {
+	// Values holds the session data.
+	Values map[interface{}]interface{}
+	// Options contains the session options, copied from the Store
+	// that created it, and may be changed per-session before Save.
+	Options *Options
+	// IsNew is true if the session is new, i.e. no matching session
+	// was found in the backend (or the cookie/id was invalid).
+	IsNew bool
+
+	store Store
+	name  string
+	id    string
+}
+
+// Options stores the configuration for a session or a Store. Fields
+// mirror the relevant subset of http.Cookie.
+type Options struct {
+	Path   string
+	Domain string
+	// MaxAge=0 means no Max-Age attribute specified and the cookie
+	// will be deleted after the browser session ends.
+	// MaxAge<0 means delete cookie immediately.
+	// MaxAge>0 means Max-Age attribute present and given in seconds.
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// NewSession is called by Store implementations to construct a new
+// Session instance.
+func NewSession(store Store, name string) *Session {
+	return &Session{
+		Values:  make(map[interface{}]interface{}),
+		Options: new(Options),
+		IsNew:   true,
+		store:   store,
+		name:    name,
+	}
+}
+
+// Name returns the name used to register the session.
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Store returns the session store used to register the session.
+func (s *Session) Store() Store {
+	return s.store
+}
+
+// Save persists the session through its backing Store.
+func (s *Session) Save(r *http.Request, w http.ResponseWriter) error {
+	return s.store.Save(r, w, s)
+}
+
+// defaultFlashKey is the Values key used for flash messages when no
+// explicit key is given.
+const defaultFlashKey = "_flash"
+
+// Flashes returns a slice of flash messages from the session, and
+// removes them from Values; a flash message only survives until it is
+// read once. The optional vars[0] names the key to use instead of the
+// default.
+func (s *Session) Flashes(vars ...string) []interface{} {
+	key := defaultFlashKey
+	if len(vars) > 0 {
+		key = vars[0]
+	}
+	var flashes []interface{}
+	if v, ok := s.Values[key]; ok {
+		flashes, _ = v.([]interface{})
+		delete(s.Values, key)
+	}
+	return flashes
+}
+
+// AddFlash adds a flash message to the session. The optional vars[0]
+// names the key to use instead of the default.
+func (s *Session) AddFlash(value interface{}, vars ...string) {
+	key := defaultFlashKey
+	if len(vars) > 0 {
+		key = vars[0]
+	}
+	var flashes []interface{}
+	if v, ok := s.Values[key]; ok {
+		flashes, _ = v.([]interface{})
+	}
+	s.Values[key] = append(flashes, value)
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 HenryLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thinkgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event is a single Server-Sent Event, written by Context.SSE using
+// the wire framing described at
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	// ID, if non-empty, is sent as the event's "id:" field and tells
+	// the client what Last-Event-ID to resume from on reconnect.
+	ID string
+	// Name, if non-empty, is sent as the event's "event:" field.
+	Name string
+	// Data is sent as one or more "data:" lines, split on '\n'.
+	Data string
+	// Retry, if non-zero, is sent as the "retry:" field, in
+	// milliseconds, telling the client how long to wait before
+	// reconnecting.
+	Retry time.Duration
+}
+
+// JSONEvent builds an Event whose Data is the JSON encoding of data.
+func JSONEvent(name string, data interface{}) (Event, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Name: name, Data: string(b)}, nil
+}
+
+// SSE streams events from ch to the client as text/event-stream,
+// flushing after each event, until ch is closed, the client
+// disconnects (detected via Response.CloseNotify), or ctx.Done fires
+// (the client disconnected, or a graceful shutdown canceled
+// RootContext). It disables gzip for this response, since compression
+// buffers output and defeats the purpose of a live stream.
+func (ctx *Context) SSE(ch <-chan Event) error {
+	ctx.enableGzip = false
+	ctx.W.Header().Set(HeaderContentType, "text/event-stream")
+	ctx.W.Header().Set(HeaderCacheControl, "no-cache")
+	ctx.W.Header().Set(HeaderConnection, "keep-alive")
+	ctx.W.WriteHeader(200)
+	ctx.W.Flush()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(ctx.W, ev); err != nil {
+				return err
+			}
+			ctx.W.Flush()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func writeEvent(w *Response, ev Event) error {
+	if ev.ID != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", ev.ID); err != nil {
+			return err
+		}
+	}
+	if ev.Name != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", ev.Name); err != nil {
+			return err
+		}
+	}
+	if ev.Retry > 0 {
+		if _, err := fmt.Fprintf(w, "retry: %d\n", ev.Retry/time.Millisecond); err != nil {
+			return err
+		}
+	}
+	for _, line := range splitLines(ev.Data) {
+		if _, err := fmt.Fprintf(w, "data: %s\n", line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "\n")
+	return err
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	lines = append(lines, s[start:])
+	return lines
+}